@@ -0,0 +1,147 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Command s3playback re-issues a request captured by agent.Config.RecordDir
+// against a different S3 endpoint, to reproduce provisioning failures from a
+// captured trace without needing live Kubernetes access.
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+
+	"github.com/huawei/cosi-driver/pkg/s3/agent"
+)
+
+func main() {
+	file := flag.String("file", "", "path to a recorded request JSON file (required)")
+	endpoint := flag.String("endpoint", "", "S3 endpoint to replay the request against, e.g. https://staging.s3.example.com (required)")
+	accessKey := flag.String("access_key", "", "access key used to re-sign the request; leave empty to replay unsigned, with the original headers minus the credential headers")
+	secretKey := flag.String("secret_key", "", "secret key used to re-sign the request")
+	httpTimeout := flag.Duration("http_timeout", 30*time.Second, "http client timeout")
+	skipVerifyTLS := flag.Bool("skip_verify_tls", false, "disable TLS certificate verification on the replayed request")
+	flag.Parse()
+
+	if err := run(*file, *endpoint, *accessKey, *secretKey, *httpTimeout, *skipVerifyTLS); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(file, endpoint, accessKey, secretKey string, httpTimeout time.Duration, skipVerifyTLS bool) error {
+	if file == "" {
+		return fmt.Errorf("-file is required")
+	}
+	if endpoint == "" {
+		return fmt.Errorf("-endpoint is required")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read recorded request [%s] failed, error is [%v]", file, err)
+	}
+
+	var record agent.RecordedRequest
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("parse recorded request [%s] failed, error is [%v]", file, err)
+	}
+
+	req, err := buildReplayRequest(record, endpoint)
+	if err != nil {
+		return fmt.Errorf("build replay request failed, error is [%v]", err)
+	}
+
+	if accessKey != "" && secretKey != "" {
+		if err := signRequest(req, []byte(record.Body), accessKey, secretKey); err != nil {
+			return fmt.Errorf("sign replay request failed, error is [%v]", err)
+		}
+	}
+
+	client := &http.Client{
+		Timeout: httpTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerifyTLS},
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("replay request failed, error is [%v]", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read replay response failed, error is [%v]", err)
+	}
+
+	fmt.Printf("status: %s\n", resp.Status)
+	fmt.Printf("body: %s\n", string(body))
+	return nil
+}
+
+// buildReplayRequest rebuilds the recorded request against endpoint, keeping
+// the original method, path, query and (non-Host) headers.
+func buildReplayRequest(record agent.RecordedRequest, endpoint string) (*http.Request, error) {
+	original, err := url.Parse(record.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse recorded url [%s] failed, error is [%v]", record.URL, err)
+	}
+
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint [%s] failed, error is [%v]", endpoint, err)
+	}
+	target.Path = original.Path
+	target.RawQuery = original.RawQuery
+
+	req, err := http.NewRequest(record.Method, target.String(), bytes.NewReader([]byte(record.Body)))
+	if err != nil {
+		return nil, err
+	}
+
+	for header, values := range record.Headers {
+		for _, value := range values {
+			req.Header.Add(header, value)
+		}
+	}
+	// The recorder redacts these to the literal string "[REDACTED]" rather
+	// than omitting them, so they must be stripped here too: sent as-is
+	// they're a bogus credential the target will reject outright, and
+	// folded into a fresh signRequest signature they produce a
+	// syntactically valid but practically broken request.
+	agent.StripSensitiveHeaders(req.Header)
+	req.Host = target.Host
+
+	return req, nil
+}
+
+// signRequest signs req with AWS SigV4 using accessKey/secretKey, replacing
+// whatever (likely redacted) Authorization header was captured.
+func signRequest(req *http.Request, body []byte, accessKey, secretKey string) error {
+	signer := v4.NewSigner(credentials.NewStaticCredentials(accessKey, secretKey, ""))
+	_, err := signer.Sign(req, bytes.NewReader(body), "s3", "us-east-1", time.Now())
+	return err
+}
@@ -0,0 +1,95 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/huawei/cosi-driver/pkg/s3/agent"
+)
+
+func TestBuildReplayRequest_StripsSensitiveHeaders(t *testing.T) {
+	record := agent.RecordedRequest{
+		Method: http.MethodPut,
+		URL:    "https://old.example.com/my-bucket/my-key?list-type=2",
+		Headers: http.Header{
+			"Authorization":        {"[REDACTED]"},
+			"X-Amz-Security-Token": {"[REDACTED]"},
+			"X-Amz-Credential":     {"[REDACTED]"},
+			"X-Amz-Signature":      {"[REDACTED]"},
+			"X-Amz-Content-Sha256": {"abc123"},
+		},
+		Body: "hello",
+	}
+
+	req, err := buildReplayRequest(record, "https://staging.example.com")
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	for _, sensitive := range []string{"Authorization", "X-Amz-Security-Token", "X-Amz-Credential", "X-Amz-Signature"} {
+		if req.Header.Get(sensitive) != "" {
+			t.Errorf("expected %s to be stripped, got [%s]", sensitive, req.Header.Get(sensitive))
+		}
+	}
+	if req.Header.Get("X-Amz-Content-Sha256") != "abc123" {
+		t.Errorf("expected non-sensitive headers to survive, got [%s]", req.Header.Get("X-Amz-Content-Sha256"))
+	}
+
+	if req.URL.Host != "staging.example.com" {
+		t.Errorf("expected host staging.example.com, got [%s]", req.URL.Host)
+	}
+	if req.URL.Path != "/my-bucket/my-key" {
+		t.Errorf("expected path /my-bucket/my-key, got [%s]", req.URL.Path)
+	}
+	if req.URL.RawQuery != "list-type=2" {
+		t.Errorf("expected query list-type=2, got [%s]", req.URL.RawQuery)
+	}
+}
+
+func TestBuildReplayRequest_InvalidEndpoint(t *testing.T) {
+	record := agent.RecordedRequest{Method: http.MethodGet, URL: "https://old.example.com/bucket"}
+
+	if _, err := buildReplayRequest(record, "://not-a-url"); err == nil {
+		t.Errorf("expected error for invalid endpoint, got nil")
+	}
+}
+
+func TestBuildReplayRequest_InvalidRecordedURL(t *testing.T) {
+	record := agent.RecordedRequest{Method: http.MethodGet, URL: "://not-a-url"}
+
+	if _, err := buildReplayRequest(record, "https://staging.example.com"); err == nil {
+		t.Errorf("expected error for invalid recorded url, got nil")
+	}
+}
+
+func TestSignRequest_SetsFreshAuthorizationHeader(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://staging.example.com/bucket", nil)
+	req.Header.Set("X-Amz-Content-Sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+
+	if err := signRequest(req, nil, "ak", "sk"); err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256") {
+		t.Errorf("expected a freshly computed SigV4 Authorization header, got [%s]", auth)
+	}
+	if strings.Contains(auth, "REDACTED") {
+		t.Errorf("expected no redacted placeholder to leak into the signature, got [%s]", auth)
+	}
+}
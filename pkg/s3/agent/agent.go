@@ -16,13 +16,14 @@
 package agent
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/url"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 
@@ -37,7 +38,19 @@ const (
 
 // S3Agent provides s3 related api
 type S3Agent struct {
-	Client *s3.S3
+	Client S3API
+}
+
+// S3API covers the subset of *s3.S3 operations the driver invokes, so
+// higher-level provisioner code can be unit-tested against a fake S3 backend
+// instead of a real endpoint. *s3.S3 satisfies this interface as-is.
+type S3API interface {
+	CreateBucket(input *s3.CreateBucketInput) (*s3.CreateBucketOutput, error)
+	DeleteBucket(input *s3.DeleteBucketInput) (*s3.DeleteBucketOutput, error)
+	HeadBucket(input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error)
+	PutBucketPolicy(input *s3.PutBucketPolicyInput) (*s3.PutBucketPolicyOutput, error)
+	GetBucketPolicy(input *s3.GetBucketPolicyInput) (*s3.GetBucketPolicyOutput, error)
+	ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
 }
 
 // Config contains the cfg information required for init S3Agent
@@ -46,8 +59,84 @@ type Config struct {
 	SecretKey string
 	Endpoint  string
 	RootCA    []byte
+
+	// InsecureSkipVerify disables server certificate verification. Useful for
+	// health-checks against RGW/MinIO backends whose certificate SAN doesn't
+	// include the in-cluster service DNS name.
+	InsecureSkipVerify bool
+
+	// ClientCert and ClientKey are PEM-encoded and, when both are set, are used
+	// to authenticate to the S3 endpoint via mutual TLS. They cannot be set
+	// together with ProxyClientCert/ProxyClientKey: crypto/tls without a
+	// per-hop GetClientCertificate callback (not implemented here) cannot
+	// guarantee which of two configured certificates is offered to which
+	// hop, so validateConfig rejects that combination outright rather than
+	// risk presenting the wrong cert to the proxy or the target.
+	ClientCert []byte
+	ClientKey  []byte
+
+	// Proxy configures the transport's proxy func. It accepts either a URL
+	// string or a func(*http.Request) (*url.URL, error). When unset, it
+	// defaults to http.ProxyFromEnvironment, so HTTPS_PROXY/HTTP_PROXY/NO_PROXY
+	// are honored out of the box.
+	Proxy interface{}
+
+	// ProxyClientCert and ProxyClientKey are PEM-encoded and, when both are
+	// set, are used to authenticate to an egress HTTPS proxy that requires
+	// mutual TLS. See the ClientCert comment above: these cannot be set
+	// together with ClientCert/ClientKey.
+	ProxyClientCert []byte
+	ProxyClientKey  []byte
+
+	// CredentialProviders selects the chain of credential sources used to
+	// authenticate to Endpoint, tried in order. Defaults to
+	// []CredentialProviderType{CredentialProviderStatic} when empty, so
+	// AccessKey/SecretKey keep working unchanged for existing callers.
+	CredentialProviders []CredentialProviderType
+
+	// RoleARN and RoleSessionName configure the CredentialProviderAssumeRole
+	// and CredentialProviderWebIdentity providers. ExternalID is used by
+	// CredentialProviderAssumeRole only.
+	RoleARN         string
+	RoleSessionName string
+	ExternalID      string
+
+	// WebIdentityTokenFile is the path to the OIDC token used by
+	// CredentialProviderWebIdentity (e.g. the IRSA projected service account
+	// token at AWS_WEB_IDENTITY_TOKEN_FILE).
+	WebIdentityTokenFile string
+
+	// HTTPClient, when set, is used as-is instead of the client NewS3Agent
+	// would otherwise build, so operators can supply a tuned, connection-pooled
+	// client shared across many bucket reconciliations. RootCA,
+	// InsecureSkipVerify, ClientCert/ClientKey and Proxy are ignored in that
+	// case, since they are baked into the supplied client's transport already.
+	HTTPClient *http.Client
+
+	// RecordDir, when set, enables the playback recorder: every outgoing S3
+	// request and its response are serialized to a JSON file in a rotating
+	// subdirectory of RecordDir, for later replay with cmd/s3playback.
+	RecordDir string
 }
 
+// CredentialProviderType names a source of AWS credentials that can be
+// chained together to authenticate an S3Agent.
+type CredentialProviderType string
+
+const (
+	// CredentialProviderStatic authenticates with Config.AccessKey/SecretKey.
+	CredentialProviderStatic CredentialProviderType = "Static"
+	// CredentialProviderEnv authenticates with AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY.
+	CredentialProviderEnv CredentialProviderType = "Env"
+	// CredentialProviderSharedFile authenticates with the shared ~/.aws/credentials file.
+	CredentialProviderSharedFile CredentialProviderType = "SharedFile"
+	// CredentialProviderAssumeRole authenticates by assuming Config.RoleARN.
+	CredentialProviderAssumeRole CredentialProviderType = "AssumeRole"
+	// CredentialProviderWebIdentity authenticates via AssumeRoleWithWebIdentity,
+	// e.g. IRSA on EKS.
+	CredentialProviderWebIdentity CredentialProviderType = "WebIdentity"
+)
+
 // NewS3Agent returns a new s3 agent
 func NewS3Agent(cfg Config) (*S3Agent, error) {
 	// Validate config fields
@@ -55,38 +144,136 @@ func NewS3Agent(cfg Config) (*S3Agent, error) {
 		return nil, err
 	}
 
-	tlsConfig, err := utils.BuildTLSConfig(cfg.RootCA)
-	if err != nil {
-		return nil, fmt.Errorf("build tls config failed, error is [%v]", err)
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		tlsConfig, err := utils.BuildTLSConfig(cfg.RootCA, cfg.InsecureSkipVerify, cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("build tls config failed, error is [%v]", err)
+		}
+
+		// validateConfig already rejected ClientCert/ClientKey and
+		// ProxyClientCert/ProxyClientKey being set together, so tlsConfig.Certificates
+		// holds at most one entry here regardless of which of the two this came from.
+		if len(cfg.ProxyClientCert) > 0 || len(cfg.ProxyClientKey) > 0 {
+			proxyCert, err := tls.X509KeyPair(cfg.ProxyClientCert, cfg.ProxyClientKey)
+			if err != nil {
+				return nil, fmt.Errorf("load proxy client key pair failed, error is [%v]", err)
+			}
+			tlsConfig.Certificates = append(tlsConfig.Certificates, proxyCert)
+		}
+
+		proxy, err := buildProxyFunc(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("build proxy func failed, error is [%v]", err)
+		}
+
+		httpClient = &http.Client{
+			Timeout: httpTimeOut,
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+				Proxy:           proxy,
+			},
+		}
+	}
+
+	if cfg.RecordDir != "" {
+		recordingClient := *httpClient
+		transport := recordingClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+
+		recorder, err := newRecordingTransport(cfg.RecordDir, transport)
+		if err != nil {
+			return nil, fmt.Errorf("init recorder failed, error is [%v]", err)
+		}
+		recordingClient.Transport = recorder
+		httpClient = &recordingClient
 	}
 
-	tr := &http.Transport{
-		TLSClientConfig: tlsConfig,
+	metricsClient := *httpClient
+	transport := metricsClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
 	}
+	metricsClient.Transport = newMetricsTransport(cfg.Endpoint, transport, defaultMetrics)
+	httpClient = &metricsClient
 
-	client := http.Client{
-		Timeout:   httpTimeOut,
-		Transport: tr,
+	creds, err := buildCredentials(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("build credentials failed, error is [%v]", err)
 	}
 
 	s, err := session.NewSession(
 		aws.NewConfig().
 			WithRegion(defaultRegion).
-			WithCredentials(credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")).
+			WithCredentials(creds).
 			WithEndpoint(cfg.Endpoint).
 			WithS3ForcePathStyle(true).
 			WithMaxRetries(maxRetries).
-			WithHTTPClient(&client),
+			WithHTTPClient(httpClient),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	s.Handlers.Send.PushFront(func(r *request.Request) {
+		if r.Operation != nil {
+			r.HTTPRequest.Header.Set(operationHeader, r.Operation.Name)
+		}
+	})
+	// AfterRetry runs after every failed attempt; only count the attempts
+	// the SDK's retry handler actually decided to retry.
+	s.Handlers.AfterRetry.PushBack(func(r *request.Request) {
+		if r.Error != nil || !aws.BoolValue(r.Retryable) {
+			return
+		}
+		operation := "unknown"
+		if r.Operation != nil {
+			operation = r.Operation.Name
+		}
+		defaultMetrics.retriesTotal.WithLabelValues(operation, cfg.Endpoint).Inc()
+	})
+
 	return &S3Agent{
 		Client: s3.New(s),
 	}, nil
 }
 
+// NewS3AgentWithClient returns a new s3 agent backed by the given S3API
+// implementation, bypassing config validation and session setup entirely.
+// This is intended for tests that exercise provisioner code against a fake
+// S3 backend.
+func NewS3AgentWithClient(api S3API) *S3Agent {
+	return &S3Agent{
+		Client: api,
+	}
+}
+
+// buildProxyFunc resolves cfg.Proxy into a transport proxy func. It accepts a
+// URL string, a func(*http.Request) (*url.URL, error), or nil, in which case
+// it falls back to http.ProxyFromEnvironment.
+func buildProxyFunc(proxy interface{}) (func(*http.Request) (*url.URL, error), error) {
+	switch p := proxy.(type) {
+	case nil:
+		return http.ProxyFromEnvironment, nil
+	case string:
+		if p == "" {
+			return http.ProxyFromEnvironment, nil
+		}
+
+		proxyURL, err := url.Parse(p)
+		if err != nil {
+			return nil, fmt.Errorf("url parse proxy [%s] failed, error is [%v]", p, err)
+		}
+		return http.ProxyURL(proxyURL), nil
+	case func(*http.Request) (*url.URL, error):
+		return p, nil
+	default:
+		return nil, fmt.Errorf("proxy must be a string or func(*http.Request) (*url.URL, error), got [%T]", proxy)
+	}
+}
+
 // validateConfig validates required fields in the Config struct
 func validateConfig(cfg Config) error {
 	if cfg.Endpoint == "" {
@@ -97,12 +284,39 @@ func validateConfig(cfg Config) error {
 		return fmt.Errorf("url parse endpoint [%s] failed, error is [%v]", cfg.Endpoint, err)
 	}
 
-	if cfg.AccessKey == "" {
-		return fmt.Errorf("access key is empty")
+	hasClientCert := len(cfg.ClientCert) > 0 || len(cfg.ClientKey) > 0
+	hasProxyClientCert := len(cfg.ProxyClientCert) > 0 || len(cfg.ProxyClientKey) > 0
+	if hasClientCert && hasProxyClientCert {
+		return fmt.Errorf("ClientCert/ClientKey and ProxyClientCert/ProxyClientKey cannot both be set: " +
+			"crypto/tls cannot guarantee which cert is presented to which hop without a per-hop " +
+			"GetClientCertificate callback, which is not implemented")
 	}
 
-	if cfg.SecretKey == "" {
-		return fmt.Errorf("secret key is empty")
+	for _, provider := range credentialProviderTypes(cfg) {
+		switch provider {
+		case CredentialProviderStatic:
+			if cfg.AccessKey == "" {
+				return fmt.Errorf("access key is empty")
+			}
+			if cfg.SecretKey == "" {
+				return fmt.Errorf("secret key is empty")
+			}
+		case CredentialProviderEnv, CredentialProviderSharedFile:
+			// no additional config required
+		case CredentialProviderAssumeRole:
+			if cfg.RoleARN == "" {
+				return fmt.Errorf("role arn is empty")
+			}
+		case CredentialProviderWebIdentity:
+			if cfg.RoleARN == "" {
+				return fmt.Errorf("role arn is empty")
+			}
+			if cfg.WebIdentityTokenFile == "" {
+				return fmt.Errorf("web identity token file is empty")
+			}
+		default:
+			return fmt.Errorf("unsupported credential provider [%s]", provider)
+		}
 	}
 
 	return nil
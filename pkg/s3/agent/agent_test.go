@@ -0,0 +1,206 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestBuildProxyFunc_Nil(t *testing.T) {
+	proxy, err := buildProxyFunc(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if proxy == nil {
+		t.Fatalf("expected a non-nil proxy func")
+	}
+}
+
+func TestBuildProxyFunc_String(t *testing.T) {
+	proxy, err := buildProxyFunc("https://proxy.example.com:3128")
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://s3.example.com/bucket", nil)
+	got, err := proxy(req)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if got.String() != "https://proxy.example.com:3128" {
+		t.Errorf("expected proxy url [https://proxy.example.com:3128], got [%s]", got.String())
+	}
+}
+
+func TestBuildProxyFunc_InvalidString(t *testing.T) {
+	if _, err := buildProxyFunc("://not-a-url"); err == nil {
+		t.Errorf("expected error for invalid proxy url, got nil")
+	}
+}
+
+func TestBuildProxyFunc_Func(t *testing.T) {
+	called := false
+	fn := func(*http.Request) (*url.URL, error) {
+		called = true
+		return nil, nil
+	}
+
+	proxy, err := buildProxyFunc(fn)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	if _, err := proxy(nil); err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if !called {
+		t.Errorf("expected the custom proxy func to be invoked")
+	}
+}
+
+func TestBuildProxyFunc_InvalidType(t *testing.T) {
+	if _, err := buildProxyFunc(42); err == nil {
+		t.Errorf("expected error for unsupported proxy type, got nil")
+	}
+}
+
+// TestNewS3Agent_RoutesThroughTLSProxy verifies that a request issued through
+// the transport built by NewS3Agent actually flows through an in-process TLS
+// proxy, using InsecureSkipVerify to trust the test proxy's self-signed cert.
+func TestNewS3Agent_RoutesThroughTLSProxy(t *testing.T) {
+	var proxyHit bool
+	proxy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyHit = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	a, err := NewS3Agent(Config{
+		Endpoint:           "http://s3.example.com",
+		AccessKey:          "ak",
+		SecretKey:          "sk",
+		InsecureSkipVerify: true,
+		Proxy:              proxy.URL,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	s3Client, ok := a.Client.(*s3.S3)
+	if !ok {
+		t.Fatalf("expected *s3.S3, got [%T]", a.Client)
+	}
+
+	metrics, ok := s3Client.Config.HTTPClient.Transport.(*metricsTransport)
+	if !ok {
+		t.Fatalf("expected *metricsTransport, got [%T]", s3Client.Config.HTTPClient.Transport)
+	}
+
+	tr, ok := metrics.next.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got [%T]", metrics.next)
+	}
+
+	client := &http.Client{Transport: tr}
+	resp, err := client.Get("http://s3.example.com/bucket")
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	defer resp.Body.Close()
+
+	if !proxyHit {
+		t.Errorf("expected the request to flow through the proxy")
+	}
+}
+
+// TestNewS3Agent_RejectsCombinedClientAndProxyCert verifies that configuring
+// both ClientCert/ClientKey and ProxyClientCert/ProxyClientKey together is
+// rejected, since crypto/tls without a per-hop GetClientCertificate callback
+// cannot guarantee which cert goes to which hop.
+func TestNewS3Agent_RejectsCombinedClientAndProxyCert(t *testing.T) {
+	_, err := NewS3Agent(Config{
+		Endpoint:        "http://s3.example.com",
+		AccessKey:       "ak",
+		SecretKey:       "sk",
+		ClientCert:      []byte("client-cert"),
+		ClientKey:       []byte("client-key"),
+		ProxyClientCert: []byte("proxy-cert"),
+		ProxyClientKey:  []byte("proxy-key"),
+	})
+	if err == nil {
+		t.Fatalf("expected an error when ClientCert and ProxyClientCert are both set")
+	}
+}
+
+// TestNewS3Agent_HTTPClientTakesPrecedence verifies that an explicit
+// Config.HTTPClient's transport is used as-is (beneath the metrics wrapper),
+// ignoring the TLS/proxy fields.
+func TestNewS3Agent_HTTPClientTakesPrecedence(t *testing.T) {
+	customTransport := &http.Transport{}
+	custom := &http.Client{Transport: customTransport}
+
+	a, err := NewS3Agent(Config{
+		Endpoint:           "https://s3.example.com",
+		AccessKey:          "ak",
+		SecretKey:          "sk",
+		InsecureSkipVerify: true,
+		HTTPClient:         custom,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	s3Client, ok := a.Client.(*s3.S3)
+	if !ok {
+		t.Fatalf("expected *s3.S3, got [%T]", a.Client)
+	}
+
+	metrics, ok := s3Client.Config.HTTPClient.Transport.(*metricsTransport)
+	if !ok {
+		t.Fatalf("expected *metricsTransport, got [%T]", s3Client.Config.HTTPClient.Transport)
+	}
+
+	if metrics.next != customTransport {
+		t.Errorf("expected the configured HTTPClient's transport to be used as-is")
+	}
+}
+
+type fakeS3API struct {
+	S3API
+	headBucketCalled bool
+}
+
+func (f *fakeS3API) HeadBucket(input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error) {
+	f.headBucketCalled = true
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func TestNewS3AgentWithClient(t *testing.T) {
+	fake := &fakeS3API{}
+
+	a := NewS3AgentWithClient(fake)
+	if _, err := a.Client.HeadBucket(&s3.HeadBucketInput{}); err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	if !fake.headBucketCalled {
+		t.Errorf("expected HeadBucket to be called on the injected client")
+	}
+}
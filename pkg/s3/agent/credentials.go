@@ -0,0 +1,106 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package agent
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// credentialProviderTypes returns cfg.CredentialProviders, defaulting to
+// CredentialProviderStatic when unset so existing AccessKey/SecretKey callers
+// keep working unchanged.
+func credentialProviderTypes(cfg Config) []CredentialProviderType {
+	if len(cfg.CredentialProviders) == 0 {
+		return []CredentialProviderType{CredentialProviderStatic}
+	}
+	return cfg.CredentialProviders
+}
+
+// buildCredentials resolves cfg.CredentialProviders into a *credentials.Credentials.
+// When a single provider is configured, it is used directly; when multiple are
+// configured, they are tried in order via credentials.NewChainCredentials.
+func buildCredentials(cfg Config) (*credentials.Credentials, error) {
+	var stsSession *session.Session
+
+	providerTypes := credentialProviderTypes(cfg)
+	providers := make([]credentials.Provider, 0, len(providerTypes))
+
+	for _, providerType := range providerTypes {
+		switch providerType {
+		case CredentialProviderStatic:
+			providers = append(providers, &credentials.StaticProvider{
+				Value: credentials.Value{
+					AccessKeyID:     cfg.AccessKey,
+					SecretAccessKey: cfg.SecretKey,
+				},
+			})
+		case CredentialProviderEnv:
+			providers = append(providers, &credentials.EnvProvider{})
+		case CredentialProviderSharedFile:
+			providers = append(providers, &credentials.SharedCredentialsProvider{})
+		case CredentialProviderAssumeRole:
+			s, err := stsSessionFor(&stsSession)
+			if err != nil {
+				return nil, err
+			}
+			assumeRole := &stscreds.AssumeRoleProvider{
+				Client:          sts.New(s),
+				RoleARN:         cfg.RoleARN,
+				RoleSessionName: cfg.RoleSessionName,
+			}
+			if cfg.ExternalID != "" {
+				assumeRole.ExternalID = aws.String(cfg.ExternalID)
+			}
+			providers = append(providers, assumeRole)
+		case CredentialProviderWebIdentity:
+			s, err := stsSessionFor(&stsSession)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, stscreds.NewWebIdentityRoleProvider(
+				sts.New(s), cfg.RoleARN, cfg.RoleSessionName, cfg.WebIdentityTokenFile,
+			))
+		default:
+			return nil, fmt.Errorf("unsupported credential provider [%s]", providerType)
+		}
+	}
+
+	if len(providers) == 1 {
+		return credentials.NewCredentials(providers[0]), nil
+	}
+	return credentials.NewChainCredentials(providers), nil
+}
+
+// stsSessionFor lazily creates (and caches in *cached) the session used to
+// issue STS calls for the AssumeRole and WebIdentity credential providers.
+func stsSessionFor(cached **session.Session) (*session.Session, error) {
+	if *cached != nil {
+		return *cached, nil
+	}
+
+	s, err := session.NewSession(aws.NewConfig().WithRegion(defaultRegion))
+	if err != nil {
+		return nil, fmt.Errorf("build sts session failed, error is [%v]", err)
+	}
+
+	*cached = s
+	return s, nil
+}
@@ -0,0 +1,128 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package agent
+
+import "testing"
+
+func TestBuildCredentials_DefaultsToStatic(t *testing.T) {
+	cfg := Config{AccessKey: "ak", SecretKey: "sk"}
+
+	creds, err := buildCredentials(cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if value.AccessKeyID != "ak" || value.SecretAccessKey != "sk" {
+		t.Errorf("expected static credentials ak/sk, got [%s]/[%s]", value.AccessKeyID, value.SecretAccessKey)
+	}
+}
+
+func TestBuildCredentials_ChainMultipleProviders(t *testing.T) {
+	cfg := Config{
+		AccessKey:           "ak",
+		SecretKey:           "sk",
+		CredentialProviders: []CredentialProviderType{CredentialProviderEnv, CredentialProviderStatic},
+	}
+
+	creds, err := buildCredentials(cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	// The env provider won't find credentials in this test process, so the
+	// chain should fall through to the static provider.
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if value.AccessKeyID != "ak" {
+		t.Errorf("expected the chain to fall back to static credentials, got [%s]", value.AccessKeyID)
+	}
+}
+
+func TestBuildCredentials_UnsupportedProvider(t *testing.T) {
+	cfg := Config{CredentialProviders: []CredentialProviderType{"bogus"}}
+
+	if _, err := buildCredentials(cfg); err == nil {
+		t.Errorf("expected error for unsupported credential provider, got nil")
+	}
+}
+
+func TestValidateConfig_CredentialProviders(t *testing.T) {
+	base := Config{Endpoint: "https://s3.example.com"}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"static missing keys", base, true},
+		{"static with keys", Config{Endpoint: base.Endpoint, AccessKey: "ak", SecretKey: "sk"}, false},
+		{
+			"assume role missing arn",
+			Config{Endpoint: base.Endpoint, CredentialProviders: []CredentialProviderType{CredentialProviderAssumeRole}},
+			true,
+		},
+		{
+			"assume role with arn",
+			Config{
+				Endpoint:            base.Endpoint,
+				CredentialProviders: []CredentialProviderType{CredentialProviderAssumeRole},
+				RoleARN:             "arn:aws:iam::123456789012:role/cosi",
+			},
+			false,
+		},
+		{
+			"web identity missing token file",
+			Config{
+				Endpoint:            base.Endpoint,
+				CredentialProviders: []CredentialProviderType{CredentialProviderWebIdentity},
+				RoleARN:             "arn:aws:iam::123456789012:role/cosi",
+			},
+			true,
+		},
+		{
+			"web identity complete",
+			Config{
+				Endpoint:             base.Endpoint,
+				CredentialProviders:  []CredentialProviderType{CredentialProviderWebIdentity},
+				RoleARN:              "arn:aws:iam::123456789012:role/cosi",
+				WebIdentityTokenFile: "/var/run/secrets/token",
+			},
+			false,
+		},
+		{
+			"unsupported provider",
+			Config{Endpoint: base.Endpoint, CredentialProviders: []CredentialProviderType{"bogus"}},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(tt.cfg)
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected no error, got [%v]", err)
+			}
+		})
+	}
+}
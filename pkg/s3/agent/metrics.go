@@ -0,0 +1,133 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package agent
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// operationHeader carries request.Request.Operation.Name from the AWS SDK
+// send handler down to metricsTransport, so the operation label reflects the
+// SDK's own request name rather than a guess parsed from the URL. It is
+// stripped before the request hits the wire.
+const operationHeader = "X-Cosi-S3-Operation"
+
+// agentMetrics holds the prometheus collectors shared by every S3Agent built
+// by NewS3Agent in this process.
+type agentMetrics struct {
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+	retriesTotal     *prometheus.CounterVec
+}
+
+func newAgentMetrics() *agentMetrics {
+	return &agentMetrics{
+		// No per-bucket label here on purpose: a long-running process
+		// provisioning many distinct buckets over its lifetime would turn
+		// every bucket name ever seen into a permanent Prometheus time
+		// series (unbounded cardinality). operation/status_code/endpoint
+		// are all drawn from small, bounded sets.
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cosi",
+			Subsystem: "s3_agent",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of S3 requests issued by the agent transport.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "status_code", "endpoint"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "cosi",
+			Subsystem: "s3_agent",
+			Name:      "requests_in_flight",
+			Help:      "Number of S3 requests currently in flight.",
+		}),
+		retriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cosi",
+			Subsystem: "s3_agent",
+			Name:      "request_retries_total",
+			Help:      "Total number of S3 request retries, by operation and endpoint.",
+		}, []string{"operation", "endpoint"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *agentMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requestDuration.Describe(ch)
+	m.requestsInFlight.Describe(ch)
+	m.retriesTotal.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *agentMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requestDuration.Collect(ch)
+	m.requestsInFlight.Collect(ch)
+	m.retriesTotal.Collect(ch)
+}
+
+// defaultMetrics is shared by every S3Agent built in this process, so the
+// COSI driver's main only has to register Metrics() once regardless of how
+// many S3Agent instances it creates.
+var defaultMetrics = newAgentMetrics()
+
+// Metrics returns the prometheus.Collector tracking S3 request counters and
+// latency histograms for every S3Agent built by NewS3Agent, so the COSI
+// driver's main can register it on its existing metrics endpoint.
+func Metrics() prometheus.Collector {
+	return defaultMetrics
+}
+
+// metricsTransport wraps an http.RoundTripper, recording per-operation
+// request latency and in-flight count. The operation label is read from
+// operationHeader rather than parsed from the URL, so it works uniformly for
+// path-style and virtual-hosted requests.
+type metricsTransport struct {
+	next     http.RoundTripper
+	metrics  *agentMetrics
+	endpoint string
+}
+
+func newMetricsTransport(endpoint string, next http.RoundTripper, metrics *agentMetrics) *metricsTransport {
+	return &metricsTransport{next: next, metrics: metrics, endpoint: endpoint}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := req.Header.Get(operationHeader)
+	if operation == "" {
+		operation = "unknown"
+	}
+	req.Header.Del(operationHeader)
+
+	t.metrics.requestsInFlight.Inc()
+	defer t.metrics.requestsInFlight.Dec()
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	duration := time.Since(start).Seconds()
+
+	statusCode := "error"
+	if resp != nil {
+		statusCode = strconv.Itoa(resp.StatusCode)
+	}
+
+	t.metrics.requestDuration.
+		WithLabelValues(operation, statusCode, t.endpoint).
+		Observe(duration)
+
+	return resp, err
+}
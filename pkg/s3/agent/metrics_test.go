@@ -0,0 +1,57 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package agent
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsTransport_RecordsDurationAndStripsHeader(t *testing.T) {
+	metrics := newAgentMetrics()
+
+	var sawOperationHeader bool
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sawOperationHeader = req.Header.Get(operationHeader) != ""
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+
+	tr := newMetricsTransport("https://s3.example.com", next, metrics)
+
+	req, _ := http.NewRequest(http.MethodPut, "https://s3.example.com/my-bucket/key", nil)
+	req.Header.Set(operationHeader, "PutObject")
+
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	defer resp.Body.Close()
+
+	if sawOperationHeader {
+		t.Errorf("expected operationHeader to be stripped before reaching the wire")
+	}
+
+	count := testutil.CollectAndCount(metrics.requestDuration)
+	if count != 1 {
+		t.Errorf("expected 1 observed duration sample, got %d", count)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+// RecordedRequest is the on-disk representation of a recorded S3 request and
+// its response. cmd/s3playback decodes these to reproduce provisioning
+// failures against a different endpoint.
+type RecordedRequest struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	Headers         http.Header `json:"headers"`
+	Body            string      `json:"body,omitempty"`
+	StatusCode      int         `json:"status_code,omitempty"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	ResponseBody    string      `json:"response_body,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// recordingTransport wraps an http.RoundTripper, serializing every request
+// and response pair it sees to a JSON file under dir.
+type recordingTransport struct {
+	next    http.RoundTripper
+	dir     string
+	counter uint64
+}
+
+// newRecordingTransport creates dir if needed and returns a transport that
+// wraps next, recording every round trip under dir.
+func newRecordingTransport(dir string, next http.RoundTripper) (*recordingTransport, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create record dir [%s] failed, error is [%v]", dir, err)
+	}
+
+	return &recordingTransport{next: next, dir: dir}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	record := RecordedRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: redactHeaders(req.Header),
+	}
+
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read request body for recording failed, error is [%v]", err)
+		}
+		record.Body = string(body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		record.Error = err.Error()
+		t.save(record)
+		return resp, err
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	record.StatusCode = resp.StatusCode
+	record.ResponseHeaders = redactHeaders(resp.Header)
+	if readErr == nil {
+		record.ResponseBody = string(respBody)
+	}
+
+	t.save(record)
+	return resp, nil
+}
+
+// save writes record to a per-day rotating subdirectory of t.dir, so a
+// long-running process doesn't accumulate unbounded entries in one directory.
+func (t *recordingTransport) save(record RecordedRequest) {
+	n := atomic.AddUint64(&t.counter, 1)
+
+	subdir := filepath.Join(t.dir, time.Now().UTC().Format("20060102"))
+	if err := os.MkdirAll(subdir, 0o750); err != nil {
+		return
+	}
+
+	name := fmt.Sprintf("%08d-%s.json", n, strings.ToLower(record.Method))
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return
+	}
+
+	_ = os.WriteFile(filepath.Join(subdir, name), data, 0o640)
+}
+
+// SensitiveHeaders lists the request/response headers that can carry usable
+// credentials and must never be written to a recorded trace verbatim, or
+// replayed as-is by cmd/s3playback: Authorization holds the SigV4 signature,
+// X-Amz-Security-Token holds the live STS session token for the
+// AssumeRole/WebIdentity credential providers (valid until STS expiry), and
+// X-Amz-Credential/X-Amz-Signature cover the equivalent query-style SigV4
+// fields on the off chance they ever show up as headers.
+var SensitiveHeaders = map[string]bool{
+	"authorization":        true,
+	"x-amz-security-token": true,
+	"x-amz-credential":     true,
+	"x-amz-signature":      true,
+}
+
+// redactHeaders returns a copy of h with SensitiveHeaders values replaced, so
+// recorded traces never contain SigV4 credentials or STS session tokens.
+func redactHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if SensitiveHeaders[strings.ToLower(k)] {
+			out[k] = []string{redactedValue}
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// StripSensitiveHeaders deletes every header in SensitiveHeaders from h in
+// place. Unlike redactHeaders, used when recording a trace, this is for
+// callers like cmd/s3playback that replay a previously-redacted trace and
+// must not forward the literal redacted placeholder value to a real
+// endpoint or fold it into a freshly computed SigV4 signature.
+func StripSensitiveHeaders(h http.Header) {
+	for k := range h {
+		if SensitiveHeaders[strings.ToLower(k)] {
+			delete(h, k)
+		}
+	}
+}
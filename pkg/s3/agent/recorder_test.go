@@ -0,0 +1,202 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRecordingTransport_WritesRequestAndResponse(t *testing.T) {
+	dir := t.TempDir()
+
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Amz-Request-Id": {"req-1"}},
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+
+	rt, err := newRecordingTransport(dir, next)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, "https://s3.example.com/bucket", strings.NewReader("body"))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=secret")
+	req.Header.Set("X-Amz-Security-Token", "FwoGZXIvYXdzEB...session-token")
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	defer resp.Body.Close()
+
+	files := findRecordedFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected exactly 1 recorded file, got %d", len(files))
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	var record RecordedRequest
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	if record.Method != http.MethodPut {
+		t.Errorf("expected method PUT, got [%s]", record.Method)
+	}
+	if record.Body != "body" {
+		t.Errorf("expected recorded body [body], got [%s]", record.Body)
+	}
+	if record.Headers.Get("Authorization") != redactedValue {
+		t.Errorf("expected Authorization to be redacted, got [%s]", record.Headers.Get("Authorization"))
+	}
+	if record.Headers.Get("X-Amz-Security-Token") != redactedValue {
+		t.Errorf("expected X-Amz-Security-Token to be redacted, got [%s]", record.Headers.Get("X-Amz-Security-Token"))
+	}
+	if record.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", record.StatusCode)
+	}
+	if record.ResponseBody != "ok" {
+		t.Errorf("expected response body [ok], got [%s]", record.ResponseBody)
+	}
+}
+
+func TestRecordingTransport_RecordsTransportError(t *testing.T) {
+	dir := t.TempDir()
+
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, os.ErrDeadlineExceeded
+	})
+
+	rt, err := newRecordingTransport(dir, next)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://s3.example.com/bucket", nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Fatalf("expected error to propagate")
+	}
+
+	files := findRecordedFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected exactly 1 recorded file, got %d", len(files))
+	}
+
+	data, _ := os.ReadFile(files[0])
+	var record RecordedRequest
+	_ = json.Unmarshal(data, &record)
+
+	if record.Error == "" {
+		t.Errorf("expected the transport error to be recorded")
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("boom")
+}
+
+func TestRecordingTransport_AbortsOnUnreadableBody(t *testing.T) {
+	dir := t.TempDir()
+
+	var nextCalled bool
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		nextCalled = true
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	rt, err := newRecordingTransport(dir, next)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, "https://s3.example.com/bucket", nil)
+	req.Body = io.NopCloser(errReader{})
+
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Fatalf("expected an error when the request body can't be read")
+	}
+
+	if nextCalled {
+		t.Errorf("expected the round trip to abort before reaching next, instead of forwarding a corrupted body")
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{
+		"Authorization":        {"AWS4-HMAC-SHA256 Credential=secret"},
+		"X-Amz-Security-Token": {"session-token"},
+		"X-Amz-Credential":     {"ak/20260726/us-east-1/s3/aws4_request"},
+		"X-Amz-Signature":      {"abc123"},
+		"X-Amz-Request-Id":     {"req-1"},
+		"Content-Type":         {"application/octet-stream"},
+	}
+
+	redacted := redactHeaders(h)
+
+	for _, sensitive := range []string{"Authorization", "X-Amz-Security-Token", "X-Amz-Credential", "X-Amz-Signature"} {
+		if redacted.Get(sensitive) != redactedValue {
+			t.Errorf("expected %s to be redacted, got [%s]", sensitive, redacted.Get(sensitive))
+		}
+	}
+
+	if redacted.Get("X-Amz-Request-Id") != "req-1" {
+		t.Errorf("expected non-sensitive headers to pass through unchanged, got [%s]", redacted.Get("X-Amz-Request-Id"))
+	}
+	if redacted.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("expected non-sensitive headers to pass through unchanged, got [%s]", redacted.Get("Content-Type"))
+	}
+}
+
+func findRecordedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+
+	return files
+}
@@ -0,0 +1,49 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package utils provides common helper functions shared across the driver
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// BuildTLSConfig builds a *tls.Config from an optional PEM-encoded root CA bundle,
+// an optional PEM-encoded client certificate/key pair (for mTLS), and an
+// insecureSkipVerify flag that disables server certificate verification.
+func BuildTLSConfig(rootCA []byte, insecureSkipVerify bool, clientCert, clientKey []byte) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if len(rootCA) > 0 {
+		pool := x509.NewCertPool()
+		if ok := pool.AppendCertsFromPEM(rootCA); !ok {
+			return nil, fmt.Errorf("failed to parse root CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(clientCert) > 0 || len(clientKey) > 0 {
+		cert, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client key pair failed, error is [%v]", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
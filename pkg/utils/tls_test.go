@@ -0,0 +1,113 @@
+/*
+ Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func generateTestCertPEM(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed, error is [%v]", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate failed, error is [%v]", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM
+}
+
+func TestBuildTLSConfig_Empty(t *testing.T) {
+	tlsConfig, err := BuildTLSConfig(nil, false, nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if tlsConfig.RootCAs != nil {
+		t.Errorf("expected nil RootCAs, got %v", tlsConfig.RootCAs)
+	}
+	if len(tlsConfig.Certificates) != 0 {
+		t.Errorf("expected no client certificates, got %d", len(tlsConfig.Certificates))
+	}
+	if tlsConfig.InsecureSkipVerify {
+		t.Errorf("expected InsecureSkipVerify to be false")
+	}
+}
+
+func TestBuildTLSConfig_InsecureSkipVerify(t *testing.T) {
+	tlsConfig, err := BuildTLSConfig(nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if !tlsConfig.InsecureSkipVerify {
+		t.Errorf("expected InsecureSkipVerify to be true")
+	}
+}
+
+func TestBuildTLSConfig_ValidRootCA(t *testing.T) {
+	certPEM, _ := generateTestCertPEM(t)
+
+	tlsConfig, err := BuildTLSConfig(certPEM, false, nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if tlsConfig.RootCAs == nil {
+		t.Errorf("expected RootCAs to be set")
+	}
+}
+
+func TestBuildTLSConfig_MalformedRootCA(t *testing.T) {
+	if _, err := BuildTLSConfig([]byte("not a cert"), false, nil, nil); err == nil {
+		t.Errorf("expected error for malformed root CA, got nil")
+	}
+}
+
+func TestBuildTLSConfig_ValidClientCert(t *testing.T) {
+	certPEM, keyPEM := generateTestCertPEM(t)
+
+	tlsConfig, err := BuildTLSConfig(nil, false, certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("expected no error, got [%v]", err)
+	}
+	if len(tlsConfig.Certificates) != 1 {
+		t.Errorf("expected 1 client certificate, got %d", len(tlsConfig.Certificates))
+	}
+}
+
+func TestBuildTLSConfig_MalformedClientCert(t *testing.T) {
+	if _, err := BuildTLSConfig(nil, false, []byte("bad cert"), []byte("bad key")); err == nil {
+		t.Errorf("expected error for malformed client cert, got nil")
+	}
+}